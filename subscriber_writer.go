@@ -0,0 +1,241 @@
+package rendezvous
+
+import (
+	"sync"
+	"time"
+
+	ggio "github.com/gogo/protobuf/io"
+	inet "github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pb "github.com/libp2p/go-libp2p-rendezvous/pb"
+)
+
+const (
+	// subscriberQueueLen bounds how many outbound records a slow subscriber
+	// can have pending before older, superseded entries start getting
+	// dropped.
+	subscriberQueueLen = 64
+	// maxWriteFailures is how many consecutive write failures (or timeouts)
+	// a subscriber tolerates before it is evicted.
+	maxWriteFailures = 3
+	// writeTimeout bounds a single outbound write, so one wedged peer can't
+	// stall its own writer goroutine indefinitely.
+	writeTimeout = 5 * time.Second
+)
+
+// subscriberWriter owns the outbound stream to a single subscriber and drains
+// a priority queue of pending records on a dedicated goroutine, so a slow or
+// dead peer can no longer stall Register/Unregister for everyone else on the
+// topic. Register and Unregister only ever enqueue onto queue and return.
+type subscriberWriter struct {
+	id     peer.ID
+	stream inet.Stream
+	w      ggio.Writer
+	queue  *subscriberQueue
+
+	// onEvict is called with sw itself so the caller can check that sw is
+	// still the installed writer for this peer before removing it from its
+	// topic's subscribers map: a reconnect can install a fresh writer for
+	// the same peer.ID while this one is still draining, and a blind
+	// lookup-then-delete by peer.ID alone would tear down the wrong one.
+	onEvict func(*subscriberWriter)
+}
+
+func newSubscriberWriter(id peer.ID, s inet.Stream, w ggio.Writer, onEvict func(*subscriberWriter)) *subscriberWriter {
+	sw := &subscriberWriter{
+		id:      id,
+		stream:  s,
+		w:       w,
+		queue:   newSubscriberQueue(subscriberQueueLen),
+		onEvict: onEvict,
+	}
+
+	go sw.run()
+
+	return sw
+}
+
+func (sw *subscriberWriter) enqueueAnnounce(rec *pb.RegistrationRecord) {
+	sw.queue.pushAnnounce(rec)
+}
+
+func (sw *subscriberWriter) enqueueTombstone(rec *pb.RegistrationRecord) {
+	sw.queue.pushTombstone(rec)
+}
+
+func (sw *subscriberWriter) enqueueReplay(recs []*pb.RegistrationRecord) {
+	sw.queue.pushReplay(recs)
+}
+
+func (sw *subscriberWriter) close() {
+	sw.queue.close()
+}
+
+func (sw *subscriberWriter) run() {
+	failures := 0
+
+	for {
+		rec, ok := sw.queue.pop()
+		if !ok {
+			return
+		}
+
+		if sw.stream != nil {
+			_ = sw.stream.SetWriteDeadline(time.Now().Add(writeTimeout))
+		}
+
+		if err := sw.w.WriteMsg(rec); err != nil {
+			failures++
+			log.Errorf("unable to write to subscriber %s: %s", sw.id, err.Error())
+
+			if failures >= maxWriteFailures {
+				log.Errorf("evicting subscriber %s after %d consecutive write failures", sw.id, failures)
+				sw.close()
+				if sw.onEvict != nil {
+					sw.onEvict(sw)
+				}
+				return
+			}
+			continue
+		}
+
+		failures = 0
+	}
+}
+
+// subscriberQueue is a bounded, three-priority outbound queue: tombstones
+// preempt regular announcements, and history replay is lowest priority. Only
+// the newest announcement per peer is kept, since an older one is always
+// superseded; on overflow beyond that, the oldest announcement or replay
+// entries are dropped rather than blocking the producer. Tombstones are never
+// dropped, so queued tombstones alone can take the queue past its capacity.
+type subscriberQueue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	cap    int
+	closed bool
+
+	tombstones []*pb.RegistrationRecord
+
+	announceOrder  []string
+	announceByPeer map[string]*pb.RegistrationRecord
+
+	replay []*pb.RegistrationRecord
+}
+
+func newSubscriberQueue(cap int) *subscriberQueue {
+	q := &subscriberQueue{
+		cap:            cap,
+		announceByPeer: map[string]*pb.RegistrationRecord{},
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *subscriberQueue) len() int {
+	return len(q.tombstones) + len(q.announceOrder) + len(q.replay)
+}
+
+func (q *subscriberQueue) pushTombstone(rec *pb.RegistrationRecord) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	q.tombstones = append(q.tombstones, rec)
+	q.evictOverflowLocked()
+	q.cond.Signal()
+}
+
+func (q *subscriberQueue) pushAnnounce(rec *pb.RegistrationRecord) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	if _, ok := q.announceByPeer[rec.Id]; !ok {
+		q.announceOrder = append(q.announceOrder, rec.Id)
+	}
+	q.announceByPeer[rec.Id] = rec
+
+	q.evictOverflowLocked()
+	q.cond.Signal()
+}
+
+func (q *subscriberQueue) pushReplay(recs []*pb.RegistrationRecord) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	q.replay = append(q.replay, recs...)
+	q.evictOverflowLocked()
+	q.cond.Signal()
+}
+
+// evictOverflowLocked drops entries until the queue is back within capacity:
+// first the oldest still-queued announcement (coalescing per peer already
+// keeps only the newest counter), then the oldest replay entry. Tombstones
+// are never dropped. Callers must hold q.mu.
+func (q *subscriberQueue) evictOverflowLocked() {
+	for q.len() > q.cap {
+		if len(q.announceOrder) > 0 {
+			oldest := q.announceOrder[0]
+			q.announceOrder = q.announceOrder[1:]
+			delete(q.announceByPeer, oldest)
+			continue
+		}
+		if len(q.replay) > 0 {
+			q.replay = q.replay[1:]
+			continue
+		}
+		break
+	}
+}
+
+// pop blocks until a record is available or the queue is closed, and returns
+// the highest-priority one: tombstones, then announcements, then replay.
+func (q *subscriberQueue) pop() (*pb.RegistrationRecord, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.len() == 0 && !q.closed {
+		q.cond.Wait()
+	}
+
+	if len(q.tombstones) > 0 {
+		rec := q.tombstones[0]
+		q.tombstones = q.tombstones[1:]
+		return rec, true
+	}
+
+	if len(q.announceOrder) > 0 {
+		id := q.announceOrder[0]
+		q.announceOrder = q.announceOrder[1:]
+		rec := q.announceByPeer[id]
+		delete(q.announceByPeer, id)
+		return rec, true
+	}
+
+	if len(q.replay) > 0 {
+		rec := q.replay[0]
+		q.replay = q.replay[1:]
+		return rec, true
+	}
+
+	return nil, false
+}
+
+func (q *subscriberQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
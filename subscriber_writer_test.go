@@ -0,0 +1,125 @@
+package rendezvous
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	pb "github.com/libp2p/go-libp2p-rendezvous/pb"
+)
+
+func TestSubscriberQueueOrdering(t *testing.T) {
+	q := newSubscriberQueue(10)
+
+	q.pushReplay([]*pb.RegistrationRecord{{Id: "replay1"}})
+	q.pushAnnounce(&pb.RegistrationRecord{Id: "peerA", Counter: 1})
+	q.pushTombstone(&pb.RegistrationRecord{Id: "tomb1", Tombstone: true})
+
+	rec, ok := q.pop()
+	if !ok || !rec.Tombstone {
+		t.Fatalf("expected tombstone first, got %+v (ok=%v)", rec, ok)
+	}
+
+	rec, ok = q.pop()
+	if !ok || rec.Id != "peerA" {
+		t.Fatalf("expected announcement second, got %+v (ok=%v)", rec, ok)
+	}
+
+	rec, ok = q.pop()
+	if !ok || rec.Id != "replay1" {
+		t.Fatalf("expected replay last, got %+v (ok=%v)", rec, ok)
+	}
+}
+
+func TestSubscriberQueueCoalescesSamePeerAnnouncements(t *testing.T) {
+	q := newSubscriberQueue(10)
+
+	q.pushAnnounce(&pb.RegistrationRecord{Id: "peerA", Counter: 1})
+	q.pushAnnounce(&pb.RegistrationRecord{Id: "peerA", Counter: 2})
+
+	if q.len() != 1 {
+		t.Fatalf("expected same-peer announcements to coalesce into one, got len %d", q.len())
+	}
+
+	rec, ok := q.pop()
+	if !ok {
+		t.Fatal("expected a record")
+	}
+	if rec.Counter != 2 {
+		t.Fatalf("expected coalesced announcement to carry the newest counter, got %d", rec.Counter)
+	}
+}
+
+func TestSubscriberQueueOverflowDropsOldestAnnouncement(t *testing.T) {
+	q := newSubscriberQueue(2)
+
+	q.pushAnnounce(&pb.RegistrationRecord{Id: "peerA", Counter: 1})
+	q.pushAnnounce(&pb.RegistrationRecord{Id: "peerB", Counter: 1})
+	// Over capacity with three distinct peers: the oldest queued
+	// announcement (peerA) should be dropped rather than blocking the
+	// producer.
+	q.pushAnnounce(&pb.RegistrationRecord{Id: "peerC", Counter: 1})
+
+	if q.len() != 2 {
+		t.Fatalf("expected queue to stay within capacity, got len %d", q.len())
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		rec, ok := q.pop()
+		if !ok {
+			t.Fatal("expected a record")
+		}
+		seen[rec.Id] = true
+	}
+
+	if seen["peerA"] {
+		t.Fatalf("expected oldest announcement (peerA) to have been evicted, got %v", seen)
+	}
+	if !seen["peerB"] || !seen["peerC"] {
+		t.Fatalf("expected peerB and peerC to survive, got %v", seen)
+	}
+}
+
+func TestSubscriberQueueNeverDropsTombstones(t *testing.T) {
+	q := newSubscriberQueue(1)
+
+	q.pushTombstone(&pb.RegistrationRecord{Id: "tomb1", Tombstone: true})
+	q.pushTombstone(&pb.RegistrationRecord{Id: "tomb2", Tombstone: true})
+
+	rec, ok := q.pop()
+	if !ok || rec.Id != "tomb1" {
+		t.Fatalf("expected tomb1 first, got %+v (ok=%v)", rec, ok)
+	}
+	rec, ok = q.pop()
+	if !ok || rec.Id != "tomb2" {
+		t.Fatalf("expected tomb2 to survive despite the cap of 1, got %+v (ok=%v)", rec, ok)
+	}
+}
+
+// alwaysFailWriter is a ggio.Writer stand-in that fails every write, used to
+// drive subscriberWriter's consecutive-failure eviction.
+type alwaysFailWriter struct{}
+
+func (alwaysFailWriter) WriteMsg(proto.Message) error {
+	return errors.New("simulated write failure")
+}
+
+func TestSubscriberWriterEvictsAfterConsecutiveFailures(t *testing.T) {
+	evicted := make(chan struct{})
+
+	sw := newSubscriberWriter("test-peer", nil, alwaysFailWriter{}, func(*subscriberWriter) {
+		close(evicted)
+	})
+
+	for _, id := range []string{"peerA", "peerB", "peerC"} {
+		sw.enqueueAnnounce(&pb.RegistrationRecord{Id: id, Counter: 1})
+	}
+
+	select {
+	case <-evicted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected subscriber to be evicted after maxWriteFailures consecutive write failures")
+	}
+}
@@ -0,0 +1,40 @@
+package rendezvous
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// RendezvousSync is the interface a rendezvous point's registration-sync
+// backend must implement: accept/withdraw registrations for a namespace, and
+// tell remote peers how to reach this backend to sync them.
+type RendezvousSync interface {
+	// GetServiceType identifies which backend this is, so callers can select
+	// among several configured ones.
+	GetServiceType() string
+
+	// Subscribe returns the backend-specific details a remote peer needs to
+	// sync registrations for ns (e.g. which peer and protocol to dial, or
+	// which pubsub topic to join).
+	Subscribe(ns string) (syncDetails string, err error)
+
+	// Register accepts pid's registration in ns. sig is pid's signature over
+	// (peerID, ns, addrs, counter, ttl), produced via SignRecord, and counter
+	// must be greater than any previously accepted counter for (pid, ns).
+	Register(pid peer.ID, ns string, addrs [][]byte, ttlAsSeconds int, counter uint64, sig []byte)
+
+	// Unregister withdraws pid's registration in ns. counter and sig are
+	// produced the same way as for Register, signing an empty addrs and a
+	// zero ttl, so the withdrawal can be authenticated like any other
+	// record instead of trusted unconditionally.
+	Unregister(pid peer.ID, ns string, counter uint64, sig []byte)
+}
+
+// RendezvousSyncSubscribable is a RendezvousSync backend that also accepts
+// inbound connections from remote peers wanting to sync directly against it.
+type RendezvousSyncSubscribable interface {
+	RendezvousSync
+
+	// Listen starts accepting whatever inbound connections this backend
+	// needs (e.g. a stream handler), if any.
+	Listen()
+}
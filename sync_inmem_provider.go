@@ -12,6 +12,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/protocol"
 	pb "github.com/libp2p/go-libp2p-rendezvous/pb"
+	tc "github.com/whyrusleeping/timecache"
 )
 
 const (
@@ -19,16 +20,52 @@ const (
 	ServiceProto = protocol.ID("/rendezvous/sync/inmem/1.0.0")
 )
 
+// Options configures the retention behaviour of a PubSub provider.
+type Options struct {
+	// HistoryLen is the maximum number of past registrations kept per
+	// namespace for replay to newly attached subscribers.
+	HistoryLen int
+	// HistoryTTL bounds how long a kept registration remains eligible for
+	// replay, regardless of HistoryLen.
+	HistoryTTL time.Duration
+	// SeenTTL bounds how long a (peerID, counter) pair is remembered in
+	// order to drop re-broadcast registrations.
+	SeenTTL time.Duration
+	// Validator, if set, is consulted before a registration is accepted and
+	// forwarded to subscribers. A nil Validator accepts every record.
+	Validator Validator
+}
+
+// DefaultOptions are the retention settings used by NewSyncInMemProvider.
+var DefaultOptions = Options{
+	HistoryLen: 32,
+	HistoryTTL: 2 * time.Minute,
+	SeenTTL:    2 * time.Minute,
+}
+
 type PubSub struct {
 	mu     sync.RWMutex
 	host   host.Host
 	topics map[string]*PubSubSubscribers
+	opts   Options
+
+	seenMu sync.Mutex
+	seen   *tc.TimeCache
+}
+
+type historyEntry struct {
+	record  *pb.RegistrationRecord
+	expires time.Time
 }
 
 type PubSubSubscribers struct {
 	mu               sync.RWMutex
-	subscribers      map[peer.ID]ggio.Writer
+	subscribers      map[peer.ID]*subscriberWriter
 	lastAnnouncement *pb.RegistrationRecord
+	history          []historyEntry
+	// lastByPeer is the last accepted record per registering peer, consulted
+	// via Validator.Select to resolve conflicting concurrent registrations.
+	lastByPeer map[string]*pb.RegistrationRecord
 }
 
 type PubSubSubscriptionDetails struct {
@@ -37,9 +74,15 @@ type PubSubSubscriptionDetails struct {
 }
 
 func NewSyncInMemProvider(host host.Host) (*PubSub, error) {
+	return NewSyncInMemProviderWithOptions(host, DefaultOptions)
+}
+
+func NewSyncInMemProviderWithOptions(host host.Host, opts Options) (*PubSub, error) {
 	ps := &PubSub{
 		host:   host,
 		topics: map[string]*PubSubSubscribers{},
+		opts:   opts,
+		seen:   tc.NewTimeCache(opts.SeenTTL),
 	}
 
 	ps.Listen()
@@ -73,35 +116,162 @@ func (ps *PubSub) getOrCreateTopic(ns string) *PubSubSubscribers {
 	}
 
 	ps.topics[ns] = &PubSubSubscribers{
-		subscribers:      map[peer.ID]ggio.Writer{},
+		subscribers:      map[peer.ID]*subscriberWriter{},
 		lastAnnouncement: nil,
+		lastByPeer:       map[string]*pb.RegistrationRecord{},
 	}
 	return ps.topics[ns]
 }
 
-func (ps *PubSub) Register(pid peer.ID, ns string, addrs [][]byte, ttlAsSeconds int, counter uint64) {
-	subscribers := ps.getOrCreateTopic(ns)
+// seenKey identifies a registration by the (peerID, ns, counter) tuple that
+// floodsub-style rebroadcast dedup keys off of.
+func seenKey(pid peer.ID, ns string, counter uint64) string {
+	return fmt.Sprintf("%s/%s/%d", ns, pid, counter)
+}
+
+func (ps *PubSub) Register(pid peer.ID, ns string, addrs [][]byte, ttlAsSeconds int, counter uint64, sig []byte) {
+	key := seenKey(pid, ns, counter)
+
+	// Has-then-Add must be atomic: two goroutines racing the identical
+	// rebroadcast must not both observe a miss and both proceed.
+	ps.seenMu.Lock()
+	if ps.seen.Has(key) {
+		ps.seenMu.Unlock()
+		return
+	}
+	ps.seen.Add(key)
+	ps.seenMu.Unlock()
+
 	dataToSend := &pb.RegistrationRecord{
-		Id:    pid.String(),
-		Addrs: addrs,
-		Ns:    ns,
-		Ttl:   time.Now().Add(time.Duration(ttlAsSeconds) * time.Second).UnixMilli(),
+		Id:        pid.String(),
+		Addrs:     addrs,
+		Ns:        ns,
+		Ttl:       time.Now().Add(time.Duration(ttlAsSeconds) * time.Second).UnixMilli(),
+		Counter:   counter,
+		Signature: sig,
 	}
 
+	if ps.opts.Validator != nil {
+		if err := ps.opts.Validator.Validate(ns, dataToSend); err != nil {
+			log.Errorf("rejecting registration for %s in %s: %s", pid, ns, err.Error())
+			return
+		}
+	}
+
+	subscribers := ps.getOrCreateTopic(ns)
+
 	subscribers.mu.Lock()
+	if ps.opts.Validator != nil {
+		if existing, ok := subscribers.lastByPeer[dataToSend.Id]; ok && ps.opts.Validator.Select(existing, dataToSend) != 1 {
+			subscribers.mu.Unlock()
+			return
+		}
+	}
+	subscribers.lastByPeer[dataToSend.Id] = dataToSend
 	subscribers.lastAnnouncement = dataToSend
-	toNotify := subscribers.subscribers
+	subscribers.pushHistory(historyEntry{
+		record:  dataToSend,
+		expires: time.Now().Add(ps.opts.HistoryTTL),
+	}, ps.opts.HistoryLen)
+	toNotify := subscribers.writersSnapshot()
 	subscribers.mu.Unlock()
 
-	for _, stream := range toNotify {
-		if err := stream.WriteMsg(dataToSend); err != nil {
-			log.Errorf("unable to notify rendezvous data update: %s", err.Error())
+	for _, writer := range toNotify {
+		writer.enqueueAnnounce(dataToSend)
+	}
+}
+
+// writersSnapshot copies the topic's current subscriber writers into a
+// slice. Callers must hold ps.mu (read or write). Ranging over ps.subscribers
+// itself after releasing the lock would race with concurrent map writes from
+// the eviction callback in subscriber_writer.go and from handleStream's
+// (un)subscribe paths.
+func (ps *PubSubSubscribers) writersSnapshot() []*subscriberWriter {
+	writers := make([]*subscriberWriter, 0, len(ps.subscribers))
+	for _, w := range ps.subscribers {
+		writers = append(writers, w)
+	}
+	return writers
+}
+
+// pushHistory appends entry to the topic's backlog, evicting the oldest
+// entries once len exceeds limit. Callers must hold ps.mu.
+func (ps *PubSubSubscribers) pushHistory(entry historyEntry, limit int) {
+	ps.history = append(ps.history, entry)
+	if len(ps.history) > limit {
+		ps.history = ps.history[len(ps.history)-limit:]
+	}
+}
+
+// unexpiredHistory returns the backlog entries that have not yet expired.
+// Callers must hold ps.mu (read or write).
+func (ps *PubSubSubscribers) unexpiredHistory() []*pb.RegistrationRecord {
+	now := time.Now()
+	records := make([]*pb.RegistrationRecord, 0, len(ps.history))
+	for _, entry := range ps.history {
+		if now.Before(entry.expires) {
+			records = append(records, entry.record)
+		}
+	}
+	return records
+}
+
+// dropHistoryFor removes any backlog entries belonging to peer id from the
+// topic's history. Callers must hold ps.mu.
+func (ps *PubSubSubscribers) dropHistoryFor(id string) {
+	filtered := ps.history[:0]
+	for _, entry := range ps.history {
+		if entry.record.Id != id {
+			filtered = append(filtered, entry)
 		}
 	}
+	ps.history = filtered
+}
+
+// IsTombstone reports whether rec announces the withdrawal of a registration
+// rather than a live one, so subscriber-side decoders know to drop the peer
+// from their local view instead of upserting it.
+func IsTombstone(rec *pb.RegistrationRecord) bool {
+	return rec.Tombstone
 }
 
-func (ps *PubSub) Unregister(p peer.ID, ns string) {
-	// TODO: unsupported
+// Unregister withdraws p's registration in ns by pushing a tombstone to every
+// subscriber. counter and sig must be produced the same way as for Register
+// (SignRecord over the (peerID, ns, addrs, counter, ttl) tuple, with
+// addrs/ttl empty/zero) and are run through the configured Validator like any
+// other record, so an unauthenticated tombstone can't be used to deregister
+// an arbitrary peer.
+func (ps *PubSub) Unregister(p peer.ID, ns string, counter uint64, sig []byte) {
+	id := p.String()
+	tombstone := &pb.RegistrationRecord{
+		Id:        id,
+		Ns:        ns,
+		Counter:   counter,
+		Signature: sig,
+		Tombstone: true,
+	}
+
+	if ps.opts.Validator != nil {
+		if err := ps.opts.Validator.Validate(ns, tombstone); err != nil {
+			log.Errorf("rejecting unregister for %s in %s: %s", p, ns, err.Error())
+			return
+		}
+	}
+
+	subscribers := ps.getOrCreateTopic(ns)
+
+	subscribers.mu.Lock()
+	if subscribers.lastAnnouncement != nil && subscribers.lastAnnouncement.Id == id {
+		subscribers.lastAnnouncement = nil
+	}
+	delete(subscribers.lastByPeer, id)
+	subscribers.dropHistoryFor(id)
+	toNotify := subscribers.writersSnapshot()
+	subscribers.mu.Unlock()
+
+	for _, writer := range toNotify {
+		writer.enqueueTombstone(tombstone)
+	}
 }
 
 func (ps *PubSub) Listen() {
@@ -114,18 +284,25 @@ func (ps *PubSub) handleStream(s inet.Stream) {
 	r := ggio.NewDelimitedReader(s, inet.MessageSizeMax)
 	w := ggio.NewDelimitedWriter(s)
 
-	subscribedTopics := map[string]struct{}{}
+	// subscribedTopics tracks, per namespace this stream subscribed to, the
+	// exact writer it installed - needed so cleanup only ever removes that
+	// writer, never one a reconnect has since replaced for the same peer.
+	subscribedTopics := map[string]*subscriberWriter{}
 
 	for {
 		var req pb.Message
 
 		err := r.ReadMsg(&req)
 		if err != nil {
-			for ns := range subscribedTopics {
+			pid := s.Conn().RemotePeer()
+			for ns, writer := range subscribedTopics {
 				topic := ps.getOrCreateTopic(ns)
 				topic.mu.Lock()
-				delete(topic.subscribers, s.Conn().RemotePeer())
+				if topic.subscribers[pid] == writer {
+					delete(topic.subscribers, pid)
+				}
 				topic.mu.Unlock()
+				writer.close()
 			}
 			return
 		}
@@ -134,23 +311,29 @@ func (ps *PubSub) handleStream(s inet.Stream) {
 			continue
 		}
 
-		topic := ps.getOrCreateTopic(req.DiscoverSubscribe.Ns)
+		pid := s.Conn().RemotePeer()
+		ns := req.DiscoverSubscribe.Ns
+		topic := ps.getOrCreateTopic(ns)
+
 		topic.mu.Lock()
-		if _, ok := topic.subscribers[s.Conn().RemotePeer()]; ok {
+		if _, ok := topic.subscribers[pid]; ok {
 			topic.mu.Unlock()
 			continue
 		}
 
-		topic.subscribers[s.Conn().RemotePeer()] = w
-		subscribedTopics[req.DiscoverSubscribe.Ns] = struct{}{}
-		lastAnnouncement := topic.lastAnnouncement
+		writer := newSubscriberWriter(pid, s, w, func(sw *subscriberWriter) {
+			topic.mu.Lock()
+			if topic.subscribers[pid] == sw {
+				delete(topic.subscribers, pid)
+			}
+			topic.mu.Unlock()
+		})
+		topic.subscribers[pid] = writer
+		subscribedTopics[ns] = writer
+		backlog := topic.unexpiredHistory()
 		topic.mu.Unlock()
 
-		if lastAnnouncement != nil {
-			if err := w.WriteMsg(lastAnnouncement); err != nil {
-				log.Errorf("unable to write announcement: %s", err.Error())
-			}
-		}
+		writer.enqueueReplay(backlog)
 	}
 }
 
@@ -0,0 +1,123 @@
+package rendezvous
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	tnet "github.com/libp2p/go-libp2p-core/test"
+)
+
+// TestRegisterUnregisterRace exercises a concurrent Register/Unregister pair
+// on the same namespace to make sure the shared topic state (lastAnnouncement,
+// history, seen-set) stays consistent under -race.
+func TestRegisterUnregisterRace(t *testing.T) {
+	h, err := libp2p.New()
+	if err != nil {
+		t.Fatalf("libp2p.New: %s", err)
+	}
+	defer h.Close()
+
+	ps, err := NewSyncInMemProvider(h)
+	if err != nil {
+		t.Fatalf("NewSyncInMemProvider: %s", err)
+	}
+
+	const ns = "race-ns"
+	pid, err := tnet.RandPeerID()
+	if err != nil {
+		t.Fatalf("RandPeerID: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := uint64(0); i < 100; i++ {
+			ps.Register(pid, ns, nil, 60, i, nil)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := uint64(0); i < 100; i++ {
+			ps.Unregister(pid, ns, i, nil)
+		}
+	}()
+
+	wg.Wait()
+
+	topic := ps.getOrCreateTopic(ns)
+	topic.mu.RLock()
+	defer topic.mu.RUnlock()
+
+	if topic.lastAnnouncement != nil && topic.lastAnnouncement.Id != pid.String() {
+		t.Fatalf("unexpected lastAnnouncement owner: %s", topic.lastAnnouncement.Id)
+	}
+	for _, entry := range topic.history {
+		if entry.record.Id != pid.String() {
+			t.Fatalf("unexpected history entry owner: %s", entry.record.Id)
+		}
+	}
+}
+
+// TestHistoryBacklogDedupAndExpiry covers the rebroadcast seen-set (a
+// re-registered counter must not grow the backlog) and the HistoryTTL-based
+// expiry of replayable entries.
+func TestHistoryBacklogDedupAndExpiry(t *testing.T) {
+	h, err := libp2p.New()
+	if err != nil {
+		t.Fatalf("libp2p.New: %s", err)
+	}
+	defer h.Close()
+
+	opts := Options{
+		HistoryLen: 2,
+		HistoryTTL: 50 * time.Millisecond,
+		SeenTTL:    time.Minute,
+	}
+
+	ps, err := NewSyncInMemProviderWithOptions(h, opts)
+	if err != nil {
+		t.Fatalf("NewSyncInMemProviderWithOptions: %s", err)
+	}
+
+	const ns = "history-ns"
+	pid, err := tnet.RandPeerID()
+	if err != nil {
+		t.Fatalf("RandPeerID: %s", err)
+	}
+
+	ps.Register(pid, ns, nil, 60, 1, nil)
+	ps.Register(pid, ns, nil, 60, 1, nil) // rebroadcast of the same counter
+
+	topic := ps.getOrCreateTopic(ns)
+
+	topic.mu.RLock()
+	historyLen := len(topic.history)
+	topic.mu.RUnlock()
+	if historyLen != 1 {
+		t.Fatalf("expected rebroadcast to be deduped, got %d history entries", historyLen)
+	}
+
+	ps.Register(pid, ns, nil, 60, 2, nil)
+	ps.Register(pid, ns, nil, 60, 3, nil)
+
+	topic.mu.RLock()
+	historyLen = len(topic.history)
+	topic.mu.RUnlock()
+	if historyLen > opts.HistoryLen {
+		t.Fatalf("history exceeded HistoryLen: %d > %d", historyLen, opts.HistoryLen)
+	}
+
+	time.Sleep(4 * opts.HistoryTTL)
+
+	topic.mu.RLock()
+	backlog := topic.unexpiredHistory()
+	topic.mu.RUnlock()
+	if len(backlog) != 0 {
+		t.Fatalf("expected history to have expired, got %d entries", len(backlog))
+	}
+}
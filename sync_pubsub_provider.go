@@ -0,0 +1,280 @@
+package rendezvous
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-rendezvous/pb"
+)
+
+const (
+	GossipServiceType  = "gossipsub"
+	GossipServiceProto = protocol.ID("/rendezvous/sync/gossipsub/1.0.0")
+
+	gossipTopicPrefix = "/rendezvous/announce/"
+)
+
+// GossipSub is a RendezvousSync provider that announces registrations over a
+// go-libp2p-pubsub (GossipSub) topic instead of direct streams to a single
+// registrar. Registrations gossip peer-to-peer across the topic mesh, so
+// subscribers no longer need a direct stream to whichever host accepted the
+// registration.
+type GossipSub struct {
+	mu        sync.RWMutex
+	host      host.Host
+	router    *pubsub.PubSub
+	validator Validator
+	topics    map[string]*gossipTopic
+}
+
+type gossipTopic struct {
+	topic  *pubsub.Topic
+	sub    *pubsub.Subscription
+	cancel context.CancelFunc
+
+	mu          sync.RWMutex
+	subscribers map[peer.ID]chan<- *pb.RegistrationRecord
+	lastByPeer  map[string]*pb.RegistrationRecord
+}
+
+// NewGossipSubProvider constructs a RendezvousSync provider backed by the
+// given pubsub router. The caller owns the router's lifecycle (it may be
+// shared with other pubsub consumers on the same host). validator may be nil,
+// in which case gossiped records are forwarded unchecked; since publishing to
+// a topic isn't gated the way a direct stream to a registrar is, operators
+// should normally supply one (e.g. NewSignedValidator()).
+func NewGossipSubProvider(host host.Host, router *pubsub.PubSub, validator Validator) (*GossipSub, error) {
+	return &GossipSub{
+		host:      host,
+		router:    router,
+		validator: validator,
+		topics:    map[string]*gossipTopic{},
+	}, nil
+}
+
+func gossipTopicName(ns string) string {
+	h := sha256.Sum256([]byte(ns))
+	return gossipTopicPrefix + hex.EncodeToString(h[:])
+}
+
+func (gs *GossipSub) GetServiceType() string {
+	return GossipServiceType
+}
+
+func (gs *GossipSub) Subscribe(ns string) (syncDetails string, err error) {
+	if _, err := gs.getOrJoinTopic(ns); err != nil {
+		return "", fmt.Errorf("unable to join gossip topic for %s: %w", ns, err)
+	}
+
+	details, err := json.Marshal(&PubSubSubscriptionDetails{
+		PeerID:      gs.host.ID().String(),
+		ChannelName: ns,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal subscription details: %w", err)
+	}
+
+	return string(details), nil
+}
+
+// Listen is a no-op for GossipSub: delivery is handled by the pubsub router's
+// own mesh, so there is no inbound stream handler to install. It exists to
+// satisfy RendezvousSyncSubscribable.
+func (gs *GossipSub) Listen() {}
+
+func (gs *GossipSub) getOrJoinTopic(ns string) (*gossipTopic, error) {
+	gs.mu.RLock()
+	if t, ok := gs.topics[ns]; ok {
+		gs.mu.RUnlock()
+		return t, nil
+	}
+	gs.mu.RUnlock()
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	if t, ok := gs.topics[ns]; ok {
+		return t, nil
+	}
+
+	topic, err := gs.router.Join(gossipTopicName(ns))
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := topic.Subscribe()
+	if err != nil {
+		topic.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &gossipTopic{
+		topic:       topic,
+		sub:         sub,
+		cancel:      cancel,
+		subscribers: map[peer.ID]chan<- *pb.RegistrationRecord{},
+		lastByPeer:  map[string]*pb.RegistrationRecord{},
+	}
+	gs.topics[ns] = t
+
+	go gs.readLoop(ctx, ns, t)
+
+	return t, nil
+}
+
+func (gs *GossipSub) readLoop(ctx context.Context, ns string, t *gossipTopic) {
+	for {
+		msg, err := t.sub.Next(ctx)
+		if err != nil {
+			return
+		}
+
+		var rec pb.RegistrationRecord
+		if err := proto.Unmarshal(msg.Data, &rec); err != nil {
+			log.Errorf("unable to unmarshal gossiped registration record: %s", err.Error())
+			continue
+		}
+
+		if gs.validator != nil {
+			if err := gs.validator.Validate(ns, &rec); err != nil {
+				log.Errorf("rejecting gossiped registration record for %s: %s", ns, err.Error())
+				continue
+			}
+		}
+
+		t.mu.Lock()
+		if !rec.Tombstone && gs.validator != nil {
+			if existing, ok := t.lastByPeer[rec.Id]; ok && gs.validator.Select(existing, &rec) != 1 {
+				t.mu.Unlock()
+				continue
+			}
+		}
+		if rec.Tombstone {
+			delete(t.lastByPeer, rec.Id)
+		} else {
+			t.lastByPeer[rec.Id] = &rec
+		}
+		// Snapshot into a slice while still holding the lock: ranging over
+		// t.subscribers itself after unlocking would race with concurrent
+		// map writes from AddSubscriber/RemoveSubscriber.
+		subscribers := make([]chan<- *pb.RegistrationRecord, 0, len(t.subscribers))
+		for _, ch := range t.subscribers {
+			subscribers = append(subscribers, ch)
+		}
+		t.mu.Unlock()
+
+		for _, ch := range subscribers {
+			select {
+			case ch <- &rec:
+			default:
+				log.Errorf("dropping gossiped registration record for %s: subscriber channel full", ns)
+			}
+		}
+	}
+}
+
+// AddSubscriber registers a local, in-process listener for registration
+// records gossiped on ns. The returned channel (buffered to 32) receives the
+// records forwarded from the topic until RemoveSubscriber is called; a record
+// arriving while the channel is full is dropped for this subscriber, not
+// queued.
+func (gs *GossipSub) AddSubscriber(p peer.ID, ns string) (<-chan *pb.RegistrationRecord, error) {
+	t, err := gs.getOrJoinTopic(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *pb.RegistrationRecord, 32)
+
+	t.mu.Lock()
+	t.subscribers[p] = ch
+	t.mu.Unlock()
+
+	return ch, nil
+}
+
+func (gs *GossipSub) RemoveSubscriber(p peer.ID, ns string) {
+	gs.mu.RLock()
+	t, ok := gs.topics[ns]
+	gs.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	delete(t.subscribers, p)
+	t.mu.Unlock()
+}
+
+func (gs *GossipSub) Register(pid peer.ID, ns string, addrs [][]byte, ttlAsSeconds int, counter uint64, sig []byte) {
+	t, err := gs.getOrJoinTopic(ns)
+	if err != nil {
+		log.Errorf("unable to join gossip topic for %s: %s", ns, err.Error())
+		return
+	}
+
+	rec := &pb.RegistrationRecord{
+		Id:        pid.String(),
+		Addrs:     addrs,
+		Ns:        ns,
+		Ttl:       time.Now().Add(time.Duration(ttlAsSeconds) * time.Second).UnixMilli(),
+		Counter:   counter,
+		Signature: sig,
+	}
+
+	data, err := proto.Marshal(rec)
+	if err != nil {
+		log.Errorf("unable to marshal registration record: %s", err.Error())
+		return
+	}
+
+	if err := t.topic.Publish(context.Background(), data); err != nil {
+		log.Errorf("unable to publish registration record for %s: %s", ns, err.Error())
+	}
+}
+
+// Unregister publishes a signed tombstone for p in ns. counter and sig must
+// be produced the same way as for Register (SignRecord over the same
+// (peerID, ns, addrs, counter, ttl) tuple, with addrs/ttl empty/zero) so that
+// receivers can run it through Validate instead of trusting it unauthenticated
+// — otherwise any peer in the topic mesh could forge a tombstone and
+// deregister an arbitrary victim.
+func (gs *GossipSub) Unregister(p peer.ID, ns string, counter uint64, sig []byte) {
+	t, err := gs.getOrJoinTopic(ns)
+	if err != nil {
+		log.Errorf("unable to join gossip topic for %s: %s", ns, err.Error())
+		return
+	}
+
+	tombstone := &pb.RegistrationRecord{
+		Id:        p.String(),
+		Ns:        ns,
+		Counter:   counter,
+		Signature: sig,
+		Tombstone: true,
+	}
+
+	data, err := proto.Marshal(tombstone)
+	if err != nil {
+		log.Errorf("unable to marshal unregister tombstone: %s", err.Error())
+		return
+	}
+
+	if err := t.topic.Publish(context.Background(), data); err != nil {
+		log.Errorf("unable to publish unregister tombstone for %s: %s", ns, err.Error())
+	}
+}
+
+var _ RendezvousSync = (*GossipSub)(nil)
+var _ RendezvousSyncSubscribable = (*GossipSub)(nil)
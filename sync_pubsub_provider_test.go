@@ -0,0 +1,86 @@
+package rendezvous
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// TestGossipSubJoinPublishReplay exercises the basic GossipSub flow end to
+// end: two hosts each running their own GossipSub provider, one registering
+// in a namespace the other has subscribed to, and the record arriving on the
+// subscriber's local channel via the topic mesh rather than a direct stream.
+func TestGossipSubJoinPublishReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hostA, err := libp2p.New()
+	if err != nil {
+		t.Fatalf("libp2p.New: %s", err)
+	}
+	defer hostA.Close()
+
+	hostB, err := libp2p.New()
+	if err != nil {
+		t.Fatalf("libp2p.New: %s", err)
+	}
+	defer hostB.Close()
+
+	if err := hostB.Connect(ctx, peer.AddrInfo{ID: hostA.ID(), Addrs: hostA.Addrs()}); err != nil {
+		t.Fatalf("Connect: %s", err)
+	}
+
+	routerA, err := pubsub.NewGossipSub(ctx, hostA)
+	if err != nil {
+		t.Fatalf("NewGossipSub (A): %s", err)
+	}
+
+	routerB, err := pubsub.NewGossipSub(ctx, hostB)
+	if err != nil {
+		t.Fatalf("NewGossipSub (B): %s", err)
+	}
+
+	providerA, err := NewGossipSubProvider(hostA, routerA, nil)
+	if err != nil {
+		t.Fatalf("NewGossipSubProvider (A): %s", err)
+	}
+
+	providerB, err := NewGossipSubProvider(hostB, routerB, nil)
+	if err != nil {
+		t.Fatalf("NewGossipSubProvider (B): %s", err)
+	}
+
+	const ns = "gossip-test-ns"
+
+	ch, err := providerB.AddSubscriber(hostB.ID(), ns)
+	if err != nil {
+		t.Fatalf("AddSubscriber: %s", err)
+	}
+
+	// The GossipSub mesh takes a heartbeat or two to form after Subscribe, so
+	// keep (re-)publishing until either the record shows up or we give up.
+	deadline := time.After(10 * time.Second)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			providerA.Register(hostA.ID(), ns, nil, 60, 1, nil)
+		case rec := <-ch:
+			if rec.Id != hostA.ID().String() {
+				t.Fatalf("unexpected registrant: %s", rec.Id)
+			}
+			if rec.Ns != ns {
+				t.Fatalf("unexpected namespace: %s", rec.Ns)
+			}
+			return
+		case <-deadline:
+			t.Fatal("timed out waiting for gossiped registration record")
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package rendezvous
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pb "github.com/libp2p/go-libp2p-rendezvous/pb"
+)
+
+// Validator lets operators plug namespace-scoped policies (allowlists, rate
+// limits, custom conflict resolution) into a sync provider. Providers call
+// Validate before accepting or forwarding a record, and Select to resolve a
+// conflict between a record they already hold and an incoming one for the
+// same (peerID, ns) pair.
+type Validator interface {
+	// Validate reports whether rec is acceptable for namespace ns. A non-nil
+	// error causes the provider to drop the record instead of accepting or
+	// forwarding it.
+	Validate(ns string, rec *pb.RegistrationRecord) error
+
+	// Select returns 0 if existing should be kept over incoming, or 1 if
+	// incoming should replace existing.
+	Select(existing, incoming *pb.RegistrationRecord) int
+}
+
+// signedPayload builds the canonical byte sequence that registrations are
+// signed over: (peerID, ns, addrs, counter, ttl).
+func signedPayload(pid peer.ID, ns string, addrs [][]byte, counter uint64, ttl int64) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(string(pid))
+	buf.WriteString(ns)
+	for _, addr := range addrs {
+		buf.Write(addr)
+	}
+
+	var num [8]byte
+	binary.BigEndian.PutUint64(num[:], counter)
+	buf.Write(num[:])
+	binary.BigEndian.PutUint64(num[:], uint64(ttl))
+	buf.Write(num[:])
+
+	return buf.Bytes()
+}
+
+// SignRecord signs a registration's (peerID, ns, addrs, counter, ttl) tuple
+// with privKey, for inclusion as the record's Signature field. privKey must
+// belong to pid.
+func SignRecord(privKey crypto.PrivKey, pid peer.ID, ns string, addrs [][]byte, counter uint64, ttl int64) ([]byte, error) {
+	return privKey.Sign(signedPayload(pid, ns, addrs, counter, ttl))
+}
+
+// SignedValidator is the default Validator: it rejects records whose
+// signature doesn't match the claimed peer ID, and records whose counter is
+// not greater than the last accepted counter for that (peerID, ns) pair.
+type SignedValidator struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// NewSignedValidator returns a Validator enforcing signature authenticity and
+// monotonically increasing counters per (peerID, ns).
+func NewSignedValidator() *SignedValidator {
+	return &SignedValidator{counters: map[string]uint64{}}
+}
+
+func (v *SignedValidator) Validate(ns string, rec *pb.RegistrationRecord) error {
+	pid, err := peer.Decode(rec.Id)
+	if err != nil {
+		return fmt.Errorf("invalid peer id in registration record: %w", err)
+	}
+
+	pub, err := pid.ExtractPublicKey()
+	if err != nil {
+		return fmt.Errorf("unable to extract public key from peer id %s: %w", pid, err)
+	}
+
+	ok, err := pub.Verify(signedPayload(pid, ns, rec.Addrs, rec.Counter, rec.Ttl), rec.Signature)
+	if err != nil {
+		return fmt.Errorf("unable to verify registration signature: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("registration signature does not match claimed peer id %s", pid)
+	}
+
+	key := ns + "/" + rec.Id
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if last, ok := v.counters[key]; ok && rec.Counter <= last {
+		return fmt.Errorf("stale registration counter %d for %s (last accepted %d)", rec.Counter, key, last)
+	}
+	v.counters[key] = rec.Counter
+
+	return nil
+}
+
+func (v *SignedValidator) Select(existing, incoming *pb.RegistrationRecord) int {
+	if incoming.Counter > existing.Counter {
+		return 1
+	}
+	return 0
+}
+
+var _ Validator = (*SignedValidator)(nil)